@@ -0,0 +1,209 @@
+package server
+
+import (
+    "context"
+    "fmt"
+    "sync"
+
+    "google.golang.org/grpc"
+    "google.golang.org/grpc/peer"
+
+    "github.com/wandb/wandb/nexus/service"
+)
+
+// nexusInternalServer implements service.NexusInternalServiceServer, wrapping
+// each RPC around the same handlers the legacy raw transport used, so the
+// wire format changes but the dispatch logic in request.go does not.
+type nexusInternalServer struct {
+    service.UnimplementedNexusInternalServiceServer
+
+    server *Server
+}
+
+// serveGRPC registers the NexusInternal service on the Server's listener and
+// blocks until it stops accepting connections.
+func (s *Server) serveGRPC() error {
+    s.grpcServer = grpc.NewServer()
+    service.RegisterNexusInternalServiceServer(s.grpcServer, &nexusInternalServer{server: s})
+    return s.grpcServer.Serve(s.listen)
+}
+
+// connRegistry is the server-side session table that lets the NexusConn an
+// InformInit call populates be found again by the RecordPublish/
+// RecordCommunicate calls that follow it. Unlike the raw transport, gRPC
+// gives handlers no connection object that survives across RPCs, so the
+// registry is keyed by client peer address and stands in for one.
+type connRegistry struct {
+    mu    sync.Mutex
+    conns map[string]*NexusConn
+}
+
+func newConnRegistry() *connRegistry {
+    return &connRegistry{conns: make(map[string]*NexusConn)}
+}
+
+// getOrCreate returns the NexusConn already registered for key, or builds
+// one with create and registers it if this is the first call seen from that
+// peer.
+func (r *connRegistry) getOrCreate(key string, create func() *NexusConn) *NexusConn {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+    if nc, ok := r.conns[key]; ok {
+        return nc
+    }
+    nc := create()
+    r.conns[key] = nc
+    return nc
+}
+
+func (r *connRegistry) remove(key string) {
+    r.mu.Lock()
+    defer r.mu.Unlock()
+    delete(r.conns, key)
+}
+
+// connKey identifies the client a gRPC call came from so repeated calls over
+// the same underlying connection resolve to the same NexusConn. Real
+// clients (the Python/Go SDKs) hold one NexusInternal connection open for
+// the lifetime of a process and make every InformInit/RecordPublish/
+// RecordCommunicate call over it, so the peer address is stable for the
+// session even though each RPC is otherwise independent.
+func connKey(ctx context.Context) (string, error) {
+    p, ok := peer.FromContext(ctx)
+    if !ok {
+        return "", fmt.Errorf("no peer info on rpc context")
+    }
+    return p.Addr.String(), nil
+}
+
+// conn resolves the NexusConn for the calling client, creating one rooted in
+// the server's context (so Server.Shutdown and per-connection teardown both
+// reach it) on the client's first call.
+func (n *nexusInternalServer) conn(ctx context.Context) (*NexusConn, error) {
+    key, err := connKey(ctx)
+    if err != nil {
+        return nil, err
+    }
+    return n.server.conns.getOrCreate(key, func() *NexusConn {
+        connCtx, cancel := context.WithCancel(n.server.ctx)
+        return &NexusConn{ctx: connCtx, cancel: cancel, server: n.server, done: make(chan bool), mux: newStreamMux(), key: key}
+    }), nil
+}
+
+func (n *nexusInternalServer) InformInit(ctx context.Context, req *service.ServerInformInitRequest) (*service.ServerInformInitResponse, error) {
+    nc, err := n.conn(ctx)
+    if err != nil {
+        return nil, err
+    }
+    wrapped := service.ServerRequest{ServerRequestType: &service.ServerRequest_InformInit{InformInit: req}}
+    if err := n.server.chain(ctx, nc, wrapped); err != nil {
+        return nil, err
+    }
+    return &service.ServerInformInitResponse{}, nil
+}
+
+func (n *nexusInternalServer) InformStart(ctx context.Context, req *service.ServerInformStartRequest) (*service.ServerInformStartResponse, error) {
+    nc, err := n.conn(ctx)
+    if err != nil {
+        return nil, err
+    }
+    wrapped := service.ServerRequest{ServerRequestType: &service.ServerRequest_InformStart{InformStart: req}}
+    if err := n.server.chain(ctx, nc, wrapped); err != nil {
+        return nil, err
+    }
+    return &service.ServerInformStartResponse{}, nil
+}
+
+func (n *nexusInternalServer) InformFinish(ctx context.Context, req *service.ServerInformFinishRequest) (*service.ServerInformFinishResponse, error) {
+    nc, err := n.conn(ctx)
+    if err != nil {
+        return nil, err
+    }
+    wrapped := service.ServerRequest{ServerRequestType: &service.ServerRequest_InformFinish{InformFinish: req}}
+    if err := n.server.chain(ctx, nc, wrapped); err != nil {
+        return nil, err
+    }
+    return &service.ServerInformFinishResponse{}, nil
+}
+
+func (n *nexusInternalServer) InformTeardown(ctx context.Context, req *service.ServerInformTeardownRequest) (*service.ServerInformTeardownResponse, error) {
+    nc, err := n.conn(ctx)
+    if err != nil {
+        return nil, err
+    }
+    wrapped := service.ServerRequest{ServerRequestType: &service.ServerRequest_InformTeardown{InformTeardown: req}}
+    if err := n.server.chain(ctx, nc, wrapped); err != nil {
+        return nil, err
+    }
+    return &service.ServerInformTeardownResponse{}, nil
+}
+
+// recvResult carries the outcome of one stream.Recv() call back to a select
+// so a publish/communicate loop can race it against the connection's ctx
+// instead of blocking on Recv indefinitely.
+type recvResult struct {
+    msg *service.Record
+    err error
+}
+
+// recvRecord calls recv in a goroutine and returns whichever of it or
+// ctx.Done() happens first. If ctx wins, the goroutine is left running: it
+// will unblock once this RPC returns and grpc-go tears down the stream's
+// transport, and the buffered channel means that send never blocks.
+func recvRecord(ctx context.Context, recv func() (*service.Record, error)) (*service.Record, error) {
+    ch := make(chan recvResult, 1)
+    go func() {
+        msg, err := recv()
+        ch <- recvResult{msg: msg, err: err}
+    }()
+
+    select {
+    case <-ctx.Done():
+        return nil, ctx.Err()
+    case r := <-ch:
+        return r.msg, r.err
+    }
+}
+
+// RecordPublish is a fire-and-forget stream of Records: no response is sent
+// back per-record, matching the semantics of the old RecordPublish framed
+// message.
+func (n *nexusInternalServer) RecordPublish(stream service.NexusInternalService_RecordPublishServer) error {
+    ctx := stream.Context()
+    nc, err := n.conn(ctx)
+    if err != nil {
+        return err
+    }
+    for {
+        msg, err := recvRecord(nc.ctx, stream.Recv)
+        if err != nil {
+            return err
+        }
+        wrapped := service.ServerRequest{ServerRequestType: &service.ServerRequest_RecordPublish{RecordPublish: msg}}
+        if err := n.server.chain(ctx, nc, wrapped); err != nil {
+            return err
+        }
+    }
+}
+
+// RecordCommunicate receives a stream of Records and runs each through the
+// handler chain. It writes no Result back per Record: a handler error,
+// including an ordering rejection, ends the stream and is returned to the
+// client as the RPC's status.
+func (n *nexusInternalServer) RecordCommunicate(stream service.NexusInternalService_RecordCommunicateServer) error {
+    ctx := stream.Context()
+    nc, err := n.conn(ctx)
+    if err != nil {
+        return err
+    }
+    for {
+        msg, err := recvRecord(nc.ctx, stream.Recv)
+        if err != nil {
+            return err
+        }
+        wrapped := service.ServerRequest{ServerRequestType: &service.ServerRequest_RecordCommunicate{RecordCommunicate: msg}}
+        if err := n.server.chain(ctx, nc, wrapped); err != nil {
+            return err
+        }
+    }
+}
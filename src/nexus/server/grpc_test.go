@@ -0,0 +1,51 @@
+package server
+
+import (
+    "context"
+    "testing"
+    "time"
+
+    "github.com/wandb/wandb/nexus/service"
+)
+
+func TestRecvRecordReturnsOnContextCancel(t *testing.T) {
+    ctx, cancel := context.WithCancel(context.Background())
+    blocked := make(chan struct{})
+
+    recv := func() (*service.Record, error) {
+        <-blocked // never unblocks in this test, mimicking an idle client
+        return nil, nil
+    }
+
+    cancel()
+
+    done := make(chan error, 1)
+    go func() {
+        _, err := recvRecord(ctx, recv)
+        done <- err
+    }()
+
+    select {
+    case err := <-done:
+        if err != context.Canceled {
+            t.Fatalf("err = %v, want %v", err, context.Canceled)
+        }
+    case <-time.After(time.Second):
+        t.Fatal("recvRecord did not return after its context was cancelled")
+    }
+}
+
+func TestRecvRecordReturnsRecvResult(t *testing.T) {
+    want := &service.Record{Num: 7}
+    recv := func() (*service.Record, error) {
+        return want, nil
+    }
+
+    msg, err := recvRecord(context.Background(), recv)
+    if err != nil {
+        t.Fatalf("unexpected error: %v", err)
+    }
+    if msg != want {
+        t.Fatalf("msg = %v, want %v", msg, want)
+    }
+}
@@ -0,0 +1,125 @@
+package server
+
+import (
+    "context"
+    "fmt"
+    "time"
+
+    "github.com/prometheus/client_golang/prometheus"
+
+    "github.com/wandb/wandb/nexus/service"
+)
+
+var (
+    requestDuration = prometheus.NewHistogramVec(
+        prometheus.HistogramOpts{
+            Name: "nexus_server_request_duration_seconds",
+            Help: "Latency of ServerRequest dispatch, by request type.",
+        },
+        []string{"type"},
+    )
+    requestTotal = prometheus.NewCounterVec(
+        prometheus.CounterOpts{
+            Name: "nexus_server_requests_total",
+            Help: "Count of ServerRequest dispatches, by request type and outcome.",
+        },
+        []string{"type", "outcome"},
+    )
+)
+
+func init() {
+    prometheus.MustRegister(requestDuration, requestTotal)
+}
+
+// Handler is the tail of an interceptor chain: the actual dispatch that
+// turns a ServerRequest into handler calls.
+type Handler func(ctx context.Context, nc *NexusConn, msg service.ServerRequest) error
+
+// ServerInterceptor wraps a Handler with cross-cutting behavior (logging,
+// metrics, auth, panic recovery, ...), calling next to continue the chain
+// or returning early to short-circuit it.
+type ServerInterceptor func(ctx context.Context, nc *NexusConn, msg service.ServerRequest, next Handler) error
+
+// Use registers an interceptor. Interceptors run in the order they were
+// added, outermost first.
+func (s *Server) Use(i ServerInterceptor) {
+    s.interceptors = append(s.interceptors, i)
+}
+
+// chain runs msg through every registered interceptor before falling
+// through to dispatchServerRequest, replacing the old direct call to
+// handleServerRequest.
+func (s *Server) chain(ctx context.Context, nc *NexusConn, msg service.ServerRequest) error {
+    h := Handler(dispatchServerRequest)
+    for i := len(s.interceptors) - 1; i >= 0; i-- {
+        interceptor := s.interceptors[i]
+        next := h
+        h = func(ctx context.Context, nc *NexusConn, msg service.ServerRequest) error {
+            return interceptor(ctx, nc, msg, next)
+        }
+    }
+    return h(ctx, nc, msg)
+}
+
+// dispatchServerRequest is handleServerRequest turned into a Handler so it
+// can sit at the end of the interceptor chain.
+func dispatchServerRequest(ctx context.Context, nc *NexusConn, msg service.ServerRequest) error {
+    return handleServerRequest(nc, msg)
+}
+
+// recoverInterceptor turns a panic in the handler chain into an error
+// instead of taking down the whole server.
+func recoverInterceptor(ctx context.Context, nc *NexusConn, msg service.ServerRequest, next Handler) (err error) {
+    defer func() {
+        if r := recover(); r != nil {
+            err = fmt.Errorf("panic handling %T: %v", msg.ServerRequestType, r)
+        }
+    }()
+    return next(ctx, nc, msg)
+}
+
+// loggingInterceptor prints one line per request, and a second one when
+// the rest of the chain returns an error. The individual handlers still
+// print their own PROCESS: lines as well.
+func loggingInterceptor(ctx context.Context, nc *NexusConn, msg service.ServerRequest, next Handler) error {
+    fmt.Printf("PROCESS: %T\n", msg.ServerRequestType)
+    err := next(ctx, nc, msg)
+    if err != nil {
+        fmt.Printf("PROCESS: %T failed: %v\n", msg.ServerRequestType, err)
+    }
+    return err
+}
+
+// metricsInterceptor records per-request-type latency and an ok/error count
+// to Prometheus, so request volume and slow handlers show up on dashboards
+// without grepping the PROCESS: log lines.
+func metricsInterceptor(ctx context.Context, nc *NexusConn, msg service.ServerRequest, next Handler) error {
+    kind := fmt.Sprintf("%T", msg.ServerRequestType)
+    start := time.Now()
+    err := next(ctx, nc, msg)
+    requestDuration.WithLabelValues(kind).Observe(time.Since(start).Seconds())
+
+    outcome := "ok"
+    if err != nil {
+        outcome = "error"
+    }
+    requestTotal.WithLabelValues(kind, outcome).Inc()
+    return err
+}
+
+// authInterceptor requires a valid bearer token on every
+// ServerInformInitRequest, so no Stream is created without one; every
+// other request type is allowed through.
+func authInterceptor(validate func(token string) bool) ServerInterceptor {
+    return func(ctx context.Context, nc *NexusConn, msg service.ServerRequest, next Handler) error {
+        init, ok := msg.ServerRequestType.(*service.ServerRequest_InformInit)
+        if !ok {
+            return next(ctx, nc, msg)
+        }
+        token := init.InformInit.GetXInfo().GetAuthToken()
+        if !validate(token) {
+            return fmt.Errorf("unauthorized: invalid or missing bearer token")
+        }
+        return next(ctx, nc, msg)
+    }
+}
@@ -0,0 +1,84 @@
+package server
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "reflect"
+    "testing"
+
+    "github.com/prometheus/client_golang/prometheus/testutil"
+
+    "github.com/wandb/wandb/nexus/service"
+)
+
+func informStart() service.ServerRequest {
+    return service.ServerRequest{ServerRequestType: &service.ServerRequest_InformStart{InformStart: &service.ServerInformStartRequest{}}}
+}
+
+func TestChainRunsInterceptorsInRegistrationOrder(t *testing.T) {
+    s := &Server{}
+    var order []string
+    mark := func(name string) ServerInterceptor {
+        return func(ctx context.Context, nc *NexusConn, msg service.ServerRequest, next Handler) error {
+            order = append(order, name)
+            return next(ctx, nc, msg)
+        }
+    }
+    s.Use(mark("first"))
+    s.Use(mark("second"))
+
+    if err := s.chain(context.Background(), nil, informStart()); err != nil {
+        t.Fatalf("chain returned error: %v", err)
+    }
+    if want := []string{"first", "second"}; !reflect.DeepEqual(order, want) {
+        t.Fatalf("call order = %v, want %v", order, want)
+    }
+}
+
+func TestRecoverInterceptorConvertsPanicToError(t *testing.T) {
+    next := func(ctx context.Context, nc *NexusConn, msg service.ServerRequest) error {
+        panic("boom")
+    }
+
+    err := recoverInterceptor(context.Background(), nil, informStart(), next)
+    if err == nil {
+        t.Fatal("expected an error recovered from the panic, got nil")
+    }
+}
+
+func TestRecoverInterceptorPassesThroughOnNoPanic(t *testing.T) {
+    wantErr := errors.New("handler failed")
+    next := func(ctx context.Context, nc *NexusConn, msg service.ServerRequest) error {
+        return wantErr
+    }
+
+    if err := recoverInterceptor(context.Background(), nil, informStart(), next); err != wantErr {
+        t.Fatalf("err = %v, want %v", err, wantErr)
+    }
+}
+
+func TestMetricsInterceptorRecordsOutcome(t *testing.T) {
+    msg := informStart()
+    kind := fmt.Sprintf("%T", msg.ServerRequestType)
+
+    okBefore := testutil.ToFloat64(requestTotal.WithLabelValues(kind, "ok"))
+    ok := func(ctx context.Context, nc *NexusConn, msg service.ServerRequest) error { return nil }
+    if err := metricsInterceptor(context.Background(), nil, msg, ok); err != nil {
+        t.Fatalf("unexpected error: %v", err)
+    }
+    if got := testutil.ToFloat64(requestTotal.WithLabelValues(kind, "ok")); got != okBefore+1 {
+        t.Fatalf("ok count = %v, want %v", got, okBefore+1)
+    }
+
+    errBefore := testutil.ToFloat64(requestTotal.WithLabelValues(kind, "error"))
+    failing := func(ctx context.Context, nc *NexusConn, msg service.ServerRequest) error {
+        return errors.New("boom")
+    }
+    if err := metricsInterceptor(context.Background(), nil, msg, failing); err == nil {
+        t.Fatal("expected metricsInterceptor to pass the handler error through")
+    }
+    if got := testutil.ToFloat64(requestTotal.WithLabelValues(kind, "error")); got != errBefore+1 {
+        t.Fatalf("error count = %v, want %v", got, errBefore+1)
+    }
+}
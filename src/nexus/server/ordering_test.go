@@ -0,0 +1,100 @@
+package server
+
+import (
+    "testing"
+    "time"
+
+    "google.golang.org/protobuf/types/known/timestamppb"
+
+    "github.com/wandb/wandb/nexus/service"
+)
+
+func historyRecord(num int64, ts time.Time) *service.Record {
+    return &service.Record{
+        Num:        num,
+        Timestamp:  timestamppb.New(ts),
+        RecordType: &service.Record_History{History: &service.HistoryRecord{}},
+    }
+}
+
+func exitRecord(num int64, ts time.Time) *service.Record {
+    return &service.Record{
+        Num:        num,
+        Timestamp:  timestamppb.New(ts),
+        RecordType: &service.Record_Exit{Exit: &service.RunExitRecord{}},
+    }
+}
+
+func TestRecordOrderAcceptsIncreasingRecords(t *testing.T) {
+    o := newRecordOrder()
+    t0 := time.Now()
+
+    if err := o.check(historyRecord(1, t0)); err != nil {
+        t.Fatalf("first record: unexpected error: %v", err)
+    }
+    if err := o.check(historyRecord(2, t0.Add(time.Second))); err != nil {
+        t.Fatalf("second record: unexpected error: %v", err)
+    }
+}
+
+func TestRecordOrderAcceptsSameTimestampWithIncreasingNum(t *testing.T) {
+    // Two records landing in the same timestamp tick (tight polling loops,
+    // coarse clock resolution) must not be rejected as long as record_num
+    // still increases.
+    o := newRecordOrder()
+    t0 := time.Now()
+
+    if err := o.check(historyRecord(1, t0)); err != nil {
+        t.Fatalf("first record: unexpected error: %v", err)
+    }
+    if err := o.check(historyRecord(2, t0)); err != nil {
+        t.Fatalf("same-timestamp record: unexpected error: %v", err)
+    }
+}
+
+func TestRecordOrderRejectsDecreasingNum(t *testing.T) {
+    o := newRecordOrder()
+    t0 := time.Now()
+
+    if err := o.check(historyRecord(5, t0)); err != nil {
+        t.Fatalf("first record: unexpected error: %v", err)
+    }
+    if err := o.check(historyRecord(4, t0.Add(time.Second))); err == nil {
+        t.Fatal("expected error for decreasing record_num, got nil")
+    }
+}
+
+func TestRecordOrderRejectsDecreasingTimestamp(t *testing.T) {
+    o := newRecordOrder()
+    t0 := time.Now()
+
+    if err := o.check(historyRecord(1, t0)); err != nil {
+        t.Fatalf("first record: unexpected error: %v", err)
+    }
+    if err := o.check(historyRecord(2, t0.Add(-time.Second))); err == nil {
+        t.Fatal("expected error for decreasing timestamp, got nil")
+    }
+}
+
+func TestRecordOrderRejectsMissingTimestamp(t *testing.T) {
+    o := newRecordOrder()
+    rec := &service.Record{
+        Num:        1,
+        RecordType: &service.Record_History{History: &service.HistoryRecord{}},
+    }
+    if err := o.check(rec); err == nil {
+        t.Fatal("expected error for missing timestamp, got nil")
+    }
+}
+
+func TestRecordOrderRejectsRecordsAfterExit(t *testing.T) {
+    o := newRecordOrder()
+    t0 := time.Now()
+
+    if err := o.check(exitRecord(1, t0)); err != nil {
+        t.Fatalf("exit record: unexpected error: %v", err)
+    }
+    if err := o.check(historyRecord(2, t0.Add(time.Second))); err == nil {
+        t.Fatal("expected error for record arriving after Exit, got nil")
+    }
+}
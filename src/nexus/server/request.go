@@ -2,7 +2,6 @@ package server
 
 import (
     // "flag"
-    "context"
     "fmt"
     // "io"
     // "google.golang.org/protobuf/reflect/protoreflect"
@@ -12,13 +11,14 @@ import (
 // import "wandb.ai/wandb/wbserver/wandb_internal":
 
 func handleInformInit(nc *NexusConn, msg *service.ServerInformInitRequest) {
-    fmt.Println("PROCESS: INIT")
+    id := msg.GetXInfo().GetStreamId()
+    fmt.Printf("PROCESS: INIT %s\n", id)
 
-    // TODO make this a mapping
     fmt.Println("STREAM init")
-    nc.mux = &Stream{}
-    nc.mux.init()
-    go nc.mux.responder(nc)
+    stream := &Stream{id: id}
+    stream.init()
+    nc.mux.add(id, stream)
+    go stream.responder(nc)
 }
 
 func handleInformStart(nc *NexusConn, msg *service.ServerInformStartRequest) {
@@ -26,22 +26,37 @@ func handleInformStart(nc *NexusConn, msg *service.ServerInformStartRequest) {
 }
 
 func handleInformFinish(nc *NexusConn, msg *service.ServerInformFinishRequest) {
-    fmt.Println("PROCESS: FIN")
+    id := msg.GetXInfo().GetStreamId()
+    fmt.Printf("PROCESS: FIN %s\n", id)
+
+    // Drop just this run's stream from the mux; nothing queued for it is
+    // drained, and its responder keeps running until the connection closes.
+    // The connection stays open for any other streams it is still
+    // multiplexing.
+    if remaining := nc.mux.remove(id); remaining == 0 {
+        nc.close()
+    }
 }
 
+// handleCommunicate returns a non-nil error, and does not forward the
+// Record, when the Record fails its ordering check. RecordCommunicate
+// returns that error as the stream's status; no Result message carries it.
+func handleCommunicate(nc *NexusConn, msg *service.Record) error {
+    if err := nc.ctx.Err(); err != nil {
+        fmt.Printf("PROCESS: COMMUNICATE dropped, connection shutting down: %v\n", err)
+        return err
+    }
+    stream := getStream(nc, msg.GetXInfo().GetStreamId())
 
-func getStream(nc *NexusConn) (*Stream) {
-    return nc.mux
-}
-
-func handleCommunicate(nc *NexusConn, msg *service.Record) {
-    stream := getStream(nc)
+    if err := stream.order.check(msg); err != nil {
+        return fmt.Errorf("out-of-order record: %w", err)
+    }
 
     ref := msg.ProtoReflect()
     desc := ref.Descriptor()
     num := ref.WhichOneof(desc.Oneofs().ByName("record_type")).Number()
     fmt.Printf("PROCESS: COMMUNICATE %d\n", num)
-    
+
     switch x := msg.RecordType.(type) {
     case *service.Record_Request:
         // fmt.Println("reqgot:", x)
@@ -53,10 +68,23 @@ func handleCommunicate(nc *NexusConn, msg *service.Record) {
         bad := fmt.Sprintf("REC UNKNOWN type %T", x)
         panic(bad)
     }
+    return nil
 }
 
+// handlePublish is fire-and-forget: there is no response path to carry an
+// ordering violation back on, so a rejected Record is just dropped with a
+// logged warning instead of returned as an error.
 func handlePublish(nc *NexusConn, msg *service.Record) {
-    stream := getStream(nc)
+    if err := nc.ctx.Err(); err != nil {
+        fmt.Printf("PROCESS: PUBLISH dropped, connection shutting down: %v\n", err)
+        return
+    }
+    stream := getStream(nc, msg.GetXInfo().GetStreamId())
+
+    if err := stream.order.check(msg); err != nil {
+        fmt.Printf("PROCESS: PUBLISH dropped out-of-order record: %v\n", err)
+        return
+    }
 
     ref := msg.ProtoReflect()
     desc := ref.Descriptor()
@@ -96,20 +124,20 @@ func handlePublish(nc *NexusConn, msg *service.Record) {
 
 func handleInformTeardown(nc *NexusConn, msg *service.ServerInformTeardownRequest) {
     fmt.Println("PROCESS: TEARDOWN")
-    nc.done <-true
-    _, cancelCtx := context.WithCancel(nc.ctx)
-
-    fmt.Println("PROCESS: TEARDOWN *****1")
-    cancelCtx()
-    fmt.Println("PROCESS: TEARDOWN *****2")
-    // TODO: remove this?
-    //os.Exit(1)
-
-    nc.server.shutdown = true
-    nc.server.listen.Close()
+    // The client tore down explicitly: drop every stream it was
+    // multiplexing, not just the last one it happened to send on, and close
+    // this one connection. This must not reach for Server.Shutdown: that
+    // takes down every other client's streams too, and calling it from
+    // inside this very RPC would leave GracefulStop waiting for a handler
+    // that can't return until GracefulStop does, until Shutdown's drain
+    // deadline forces a hard stop.
+    for _, id := range nc.mux.ids() {
+        nc.mux.remove(id)
+    }
+    nc.close()
 }
 
-func handleServerRequest(nc *NexusConn, msg service.ServerRequest) {
+func handleServerRequest(nc *NexusConn, msg service.ServerRequest) error {
     switch x := msg.ServerRequestType.(type) {
     case *service.ServerRequest_InformInit:
         handleInformInit(nc, x.InformInit)
@@ -120,7 +148,7 @@ func handleServerRequest(nc *NexusConn, msg service.ServerRequest) {
     case *service.ServerRequest_RecordPublish:
         handlePublish(nc, x.RecordPublish)
     case *service.ServerRequest_RecordCommunicate:
-        handleCommunicate(nc, x.RecordCommunicate)
+        return handleCommunicate(nc, x.RecordCommunicate)
     case *service.ServerRequest_InformTeardown:
         handleInformTeardown(nc, x.InformTeardown)
     case nil:
@@ -130,4 +158,5 @@ func handleServerRequest(nc *NexusConn, msg service.ServerRequest) {
         bad := fmt.Sprintf("UNKNOWN type %T", x)
         panic(bad)
     }
+    return nil
 }
@@ -0,0 +1,144 @@
+package server
+
+import (
+    "context"
+    "fmt"
+    "net"
+    "sync"
+    "time"
+
+    "google.golang.org/grpc"
+)
+
+// defaultDrainTimeout bounds each of Shutdown's two waits: for in-flight
+// RPCs to return, then for stream goroutines to exit, before the listener
+// is closed.
+const defaultDrainTimeout = 5 * time.Second
+
+// Server accepts client connections and dispatches the ServerRequest/Record
+// protobuf messages it receives to the handlers in request.go.
+type Server struct {
+    ctx    context.Context
+    cancel context.CancelFunc
+
+    listen net.Listener
+
+    grpcServer   *grpc.Server
+    shutdown     bool
+    drainTimeout time.Duration
+
+    // wg tracks every Stream's responder goroutine across every
+    // connection, so Shutdown knows when it is safe to close listen.
+    wg sync.WaitGroup
+
+    // conns is the session registry gRPC calls use to find the NexusConn an
+    // earlier InformInit populated; see connRegistry in grpc.go.
+    conns *connRegistry
+
+    interceptors []ServerInterceptor
+}
+
+// NexusConn is a single client's gRPC session. Its ctx is a child of the
+// Server's root context, cancelled either by Server.Shutdown (every
+// connection at once) or by close (just this one, e.g. on InformTeardown).
+type NexusConn struct {
+    ctx    context.Context
+    cancel context.CancelFunc
+    server *Server
+    done   chan bool
+
+    // key is this connection's entry in server.conns.
+    key string
+
+    closeOnce sync.Once
+
+    mux *streamMux
+}
+
+// close cancels the connection's own context and drops it from the
+// server's session registry so a later reconnect from the same peer starts
+// fresh, then closes done so anything still selecting on it unblocks.
+func (nc *NexusConn) close() {
+    nc.closeOnce.Do(func() {
+        nc.cancel()
+        nc.server.conns.remove(nc.key)
+        close(nc.done)
+    })
+}
+
+// NewServer creates a Server that will accept connections on listen once
+// Serve is called.
+func NewServer(ctx context.Context, listen net.Listener) *Server {
+    ctx, cancel := context.WithCancel(ctx)
+    s := &Server{
+        ctx:          ctx,
+        cancel:       cancel,
+        listen:       listen,
+        drainTimeout: defaultDrainTimeout,
+        conns:        newConnRegistry(),
+    }
+    s.Use(recoverInterceptor)
+    s.Use(metricsInterceptor)
+    s.Use(loggingInterceptor)
+    return s
+}
+
+// Shutdown mirrors http.Server.Shutdown: it stops accepting new
+// connections, cancels the root context so every NexusConn and Stream
+// goroutine derived from it observes ctx.Done() (which the RecordPublish/
+// RecordCommunicate loops in grpc.go select on to unblock from a pending
+// Recv), then waits up to drainTimeout for in-flight RPCs to return
+// (forcing a hard stop after that) and up to drainTimeout again for stream
+// goroutines to exit, either wait ending early if ctx is done, before
+// closing the listener. Streams hold no outbound queues, so nothing is
+// flushed. Safe to call from a SIGTERM handler.
+func (s *Server) Shutdown(ctx context.Context) error {
+    s.shutdown = true
+    // Cancel before GracefulStop: GracefulStop blocks until every in-flight
+    // RPC returns on its own, and a RecordPublish/RecordCommunicate loop
+    // only returns once it sees ctx cancelled, so cancelling first is what
+    // lets those RPCs finish instead of GracefulStop waiting forever.
+    s.cancel()
+
+    if s.grpcServer != nil {
+        stopped := make(chan struct{})
+        go func() {
+            s.grpcServer.GracefulStop()
+            close(stopped)
+        }()
+
+        select {
+        case <-stopped:
+        case <-time.After(s.drainTimeout):
+            fmt.Println("PROCESS: shutdown drain deadline exceeded, forcing grpc stop")
+            s.grpcServer.Stop()
+            <-stopped
+        case <-ctx.Done():
+            s.grpcServer.Stop()
+            <-stopped
+        }
+    }
+
+    drained := make(chan struct{})
+    go func() {
+        s.wg.Wait()
+        close(drained)
+    }()
+
+    select {
+    case <-drained:
+    case <-time.After(s.drainTimeout):
+        fmt.Println("PROCESS: shutdown drain deadline exceeded, closing anyway")
+    case <-ctx.Done():
+    }
+
+    return s.listen.Close()
+}
+
+// Serve accepts connections on the Server's listener until it is closed,
+// speaking the NexusInternal gRPC service. There is no fallback to the
+// pre-gRPC framed protocol and no flag to select one: every connection is
+// served over gRPC.
+func (s *Server) Serve() error {
+    return s.serveGRPC()
+}
@@ -0,0 +1,71 @@
+package server
+
+import (
+    "context"
+    "net"
+    "testing"
+    "time"
+
+    "google.golang.org/grpc"
+    "google.golang.org/grpc/test/bufconn"
+
+    "github.com/wandb/wandb/nexus/service"
+)
+
+const bufSize = 1024 * 1024
+
+// startTestServer spins up a Server over an in-memory bufconn listener and
+// returns it with a dialed client connection to it. The connection is
+// closed by t.Cleanup; the server is left for the test to shut down.
+func startTestServer(t *testing.T) (*Server, *grpc.ClientConn) {
+    t.Helper()
+    lis := bufconn.Listen(bufSize)
+
+    s := NewServer(context.Background(), lis)
+    s.drainTimeout = 200 * time.Millisecond
+
+    go func() {
+        _ = s.Serve()
+    }()
+
+    conn, err := grpc.DialContext(context.Background(), "bufnet",
+        grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+            return lis.DialContext(ctx)
+        }),
+        grpc.WithInsecure(),
+    )
+    if err != nil {
+        t.Fatalf("dial: %v", err)
+    }
+    t.Cleanup(func() { conn.Close() })
+
+    return s, conn
+}
+
+// TestShutdownReturnsWithStreamOpen proves the GracefulStop/drainTimeout fix:
+// a client holding a RecordPublish stream open without closing it - the
+// normal steady state during a live run - must not make Shutdown hang.
+func TestShutdownReturnsWithStreamOpen(t *testing.T) {
+    s, conn := startTestServer(t)
+    client := service.NewNexusInternalServiceClient(conn)
+
+    stream, err := client.RecordPublish(context.Background())
+    if err != nil {
+        t.Fatalf("RecordPublish: %v", err)
+    }
+    t.Cleanup(func() { stream.CloseSend() })
+
+    done := make(chan error, 1)
+    go func() {
+        done <- s.Shutdown(context.Background())
+    }()
+
+    select {
+    case err := <-done:
+        if err != nil {
+            t.Fatalf("Shutdown returned error: %v", err)
+        }
+    case <-time.After(5 * s.drainTimeout):
+        t.Fatal("Shutdown did not return within the drain deadline with a stream left open")
+    }
+}
@@ -0,0 +1,90 @@
+package server
+
+import (
+    "fmt"
+    "sync"
+)
+
+// Stream carries the state for a single run: its id and the ordering state
+// its Records are checked against. Its only goroutine is responder.
+type Stream struct {
+    id    string
+    order *recordOrder
+}
+
+func (s *Stream) init() {
+    s.order = newRecordOrder()
+}
+
+// responder blocks until the connection's context is cancelled and sends
+// nothing to the client. It outlives a stream removed while its connection
+// stays open, and is counted in Server.wg so Server.Shutdown can wait on it
+// during drain.
+func (s *Stream) responder(nc *NexusConn) {
+    nc.server.wg.Add(1)
+    defer nc.server.wg.Done()
+
+    <-nc.ctx.Done()
+}
+
+// streamMux tracks the set of Streams multiplexed over one NexusConn, keyed
+// by run_id/stream_id, so a single agent process can host many in-flight
+// runs at once (sweeps, multi-process training).
+type streamMux struct {
+    mu      sync.RWMutex
+    streams map[string]*Stream
+}
+
+func newStreamMux() *streamMux {
+    return &streamMux{streams: make(map[string]*Stream)}
+}
+
+func (m *streamMux) add(id string, s *Stream) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    m.streams[id] = s
+}
+
+func (m *streamMux) get(id string) (*Stream, bool) {
+    m.mu.RLock()
+    defer m.mu.RUnlock()
+    s, ok := m.streams[id]
+    return s, ok
+}
+
+// remove drops the stream for id and reports how many streams remain, so
+// callers know whether the connection can be closed.
+func (m *streamMux) remove(id string) int {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    delete(m.streams, id)
+    return len(m.streams)
+}
+
+// ids returns a snapshot of the currently multiplexed stream ids.
+func (m *streamMux) ids() []string {
+    m.mu.RLock()
+    defer m.mu.RUnlock()
+    ids := make([]string, 0, len(m.streams))
+    for id := range m.streams {
+        ids = append(ids, id)
+    }
+    return ids
+}
+
+func (m *streamMux) len() int {
+    m.mu.RLock()
+    defer m.mu.RUnlock()
+    return len(m.streams)
+}
+
+// getStream looks up the Stream for id, panicking the same way the rest of
+// this package does on an unexpected/unknown message shape: a request for a
+// stream that was never initialized is a client protocol violation.
+func getStream(nc *NexusConn, id string) *Stream {
+    s, ok := nc.mux.get(id)
+    if !ok {
+        panic(fmt.Sprintf("unknown stream id %q", id))
+    }
+    return s
+}
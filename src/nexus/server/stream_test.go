@@ -0,0 +1,112 @@
+package server
+
+import (
+    "context"
+    "fmt"
+    "sync"
+    "testing"
+)
+
+func TestStreamMuxAddGetRemove(t *testing.T) {
+    m := newStreamMux()
+    s := &Stream{id: "run-1"}
+
+    m.add("run-1", s)
+
+    got, ok := m.get("run-1")
+    if !ok || got != s {
+        t.Fatalf("get(run-1) = %v, %v; want %v, true", got, ok, s)
+    }
+    if m.len() != 1 {
+        t.Fatalf("len() = %d, want 1", m.len())
+    }
+
+    if remaining := m.remove("run-1"); remaining != 0 {
+        t.Fatalf("remove(run-1) remaining = %d, want 0", remaining)
+    }
+    if _, ok := m.get("run-1"); ok {
+        t.Fatal("get(run-1) succeeded after remove")
+    }
+}
+
+func TestStreamMuxIdsSnapshot(t *testing.T) {
+    m := newStreamMux()
+    m.add("a", &Stream{id: "a"})
+    m.add("b", &Stream{id: "b"})
+
+    ids := m.ids()
+    if len(ids) != 2 {
+        t.Fatalf("ids() = %v, want 2 entries", ids)
+    }
+    seen := map[string]bool{}
+    for _, id := range ids {
+        seen[id] = true
+    }
+    if !seen["a"] || !seen["b"] {
+        t.Fatalf("ids() = %v, want a and b", ids)
+    }
+}
+
+func TestStreamMuxConcurrentAddRemove(t *testing.T) {
+    m := newStreamMux()
+    const n = 100
+
+    var wg sync.WaitGroup
+    for i := 0; i < n; i++ {
+        wg.Add(1)
+        go func(i int) {
+            defer wg.Done()
+            id := fmt.Sprintf("run-%d", i)
+            m.add(id, &Stream{id: id})
+        }(i)
+    }
+    wg.Wait()
+
+    if m.len() != n {
+        t.Fatalf("len() = %d, want %d", m.len(), n)
+    }
+
+    wg = sync.WaitGroup{}
+    for i := 0; i < n; i++ {
+        wg.Add(1)
+        go func(i int) {
+            defer wg.Done()
+            m.remove(fmt.Sprintf("run-%d", i))
+        }(i)
+    }
+    wg.Wait()
+
+    if m.len() != 0 {
+        t.Fatalf("len() = %d after concurrent remove, want 0", m.len())
+    }
+}
+
+// TestLastStreamRemovedClosesConnection mirrors handleInformFinish's
+// "remaining == 0 closes the connection" rule: the NexusConn should only be
+// closed once the last multiplexed stream on it is gone, not before.
+func TestLastStreamRemovedClosesConnection(t *testing.T) {
+    ctx, cancel := context.WithCancel(context.Background())
+    nc := &NexusConn{ctx: ctx, cancel: cancel, server: &Server{conns: newConnRegistry()}, done: make(chan bool), mux: newStreamMux()}
+    nc.mux.add("run-1", &Stream{id: "run-1"})
+    nc.mux.add("run-2", &Stream{id: "run-2"})
+
+    if remaining := nc.mux.remove("run-1"); remaining == 0 {
+        t.Fatal("removing one of two streams reported the connection as empty")
+    }
+    select {
+    case <-nc.done:
+        t.Fatal("connection closed before its last stream was removed")
+    default:
+    }
+
+    if remaining := nc.mux.remove("run-2"); remaining != 0 {
+        t.Fatalf("removing the last stream left %d remaining, want 0", remaining)
+    }
+    nc.close()
+
+    select {
+    case <-nc.done:
+    default:
+        t.Fatal("connection not closed after its last stream was removed")
+    }
+}